@@ -0,0 +1,95 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "matrix_static_request_duration_seconds",
+		Help: "Latency of handled HTTP requests, by matched route.",
+	}, []string{"route", "status"})
+
+	roomCount = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "matrix_static_room_count",
+		Help: "Number of rooms currently loaded by the client.",
+	})
+
+	roomMemberCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "matrix_static_room_member_count",
+		Help: "Number of members in a room, by room ID.",
+	}, []string{"room_id"})
+
+	forwardPaginationDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "matrix_static_forward_pagination_duration_seconds",
+		Help: "Duration of the periodic lazy forward-pagination sweep across all rooms.",
+	})
+
+	publicRoomListRefreshDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "matrix_static_public_room_list_refresh_duration_seconds",
+		Help: "Duration of refreshing the public rooms list from the homeserver.",
+	})
+)
+
+// structuredLogger replaces gin's default text logger with one JSON line
+// per request, including the matched route and whether the room the
+// request was for was served from cache.
+func structuredLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		status := c.Writer.Status()
+		duration := time.Since(start)
+
+		requestDuration.WithLabelValues(route, statusLabel(status)).Observe(duration.Seconds())
+
+		cacheStatus, ok := c.Get("CacheStatus")
+		if !ok {
+			cacheStatus = "n/a"
+		}
+
+		log.Info().
+			Str("route", route).
+			Str("method", c.Request.Method).
+			Int("status", status).
+			Str("room_id", c.Param("roomID")).
+			Str("cache", cacheStatus.(string)).
+			Dur("duration", duration).
+			Msg("request handled")
+	}
+}
+
+func statusLabel(status int) string {
+	switch {
+	case status >= 500:
+		return "5xx"
+	case status >= 400:
+		return "4xx"
+	case status >= 300:
+		return "3xx"
+	default:
+		return "2xx"
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// ServerACL is a compiled view of a room's m.room.server_acl state, letting
+// callers check whether a given server is currently allowed to participate
+// in the room without re-parsing the globs on every lookup. Allow and Deny
+// hold the original glob patterns (for display in the servers view);
+// allow/deny hold them pre-compiled (for matching).
+type ServerACL struct {
+	AllowIPLiterals bool
+	Allow           []string
+	Deny            []string
+
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+// ServerACL compiles the room's current m.room.server_acl state, if any.
+// Rooms without an ACL event return a permissive ServerACL that allows
+// everything, matching homeserver behaviour.
+func (r *Room) ServerACL() *ServerACL {
+	ev := r.GetStateEvent("m.room.server_acl", "")
+	if ev == nil {
+		return &ServerACL{
+			AllowIPLiterals: true,
+			Allow:           []string{"*"},
+			allow:           []*regexp.Regexp{globToRegexp("*")},
+		}
+	}
+
+	acl := &ServerACL{}
+
+	if allowIPLiterals, ok := ev.Content["allow_ip_literals"].(bool); ok {
+		acl.AllowIPLiterals = allowIPLiterals
+	}
+
+	acl.Allow = stringSlice(asInterfaceSlice(ev.Content["allow"]))
+	acl.Deny = stringSlice(asInterfaceSlice(ev.Content["deny"]))
+
+	for _, pattern := range acl.Allow {
+		acl.allow = append(acl.allow, globToRegexp(pattern))
+	}
+	for _, pattern := range acl.Deny {
+		acl.deny = append(acl.deny, globToRegexp(pattern))
+	}
+
+	return acl
+}
+
+// IsServerAllowed reports whether server is currently permitted to
+// participate in the room under this ACL: not matched by any deny glob, and
+// either matched by an allow glob or (when there are no allow globs at all)
+// allowed by default.
+func (acl *ServerACL) IsServerAllowed(server string) bool {
+	if !acl.AllowIPLiterals && isIPLiteral(server) {
+		return false
+	}
+
+	for _, deny := range acl.deny {
+		if deny.MatchString(server) {
+			return false
+		}
+	}
+
+	if len(acl.allow) == 0 {
+		return true
+	}
+	for _, allow := range acl.allow {
+		if allow.MatchString(server) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsServerAllowed is a convenience wrapper around Room.ServerACL().IsServerAllowed.
+func (r *Room) IsServerAllowed(server string) bool {
+	return r.ServerACL().IsServerAllowed(server)
+}
+
+func isIPLiteral(server string) bool {
+	host := server
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+	return net.ParseIP(strings.Trim(host, "[]")) != nil
+}
+
+// globToRegexp compiles a Matrix server ACL glob (where `*` matches any
+// number of characters and `?` matches exactly one) into an anchored regexp.
+func globToRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		// An unparsable glob shouldn't ever match.
+		return regexp.MustCompile(`[^\x00-\x{10FFFF}]`)
+	}
+	return re
+}
+
+func asInterfaceSlice(v interface{}) []interface{} {
+	raw, _ := v.([]interface{})
+	return raw
+}
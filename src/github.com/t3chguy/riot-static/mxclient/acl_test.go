@@ -0,0 +1,98 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	cases := []struct {
+		pattern string
+		server  string
+		want    bool
+	}{
+		{"*", "example.org", true},
+		{"*.example.org", "matrix.example.org", true},
+		{"*.example.org", "example.org", false},
+		{"example.org", "evilexample.org", false},
+		{"ex?mple.org", "example.org", true},
+		{"ex?mple.org", "exxxmple.org", false},
+	}
+
+	for _, c := range cases {
+		if got := globToRegexp(c.pattern).MatchString(c.server); got != c.want {
+			t.Errorf("globToRegexp(%q).MatchString(%q) = %v, want %v", c.pattern, c.server, got, c.want)
+		}
+	}
+}
+
+func TestIsServerAllowed(t *testing.T) {
+	acl := &ServerACL{
+		AllowIPLiterals: false,
+		allow:           []*regexp.Regexp{globToRegexp("*.example.org"), globToRegexp("trusted.net")},
+		deny:            []*regexp.Regexp{globToRegexp("evil.example.org")},
+	}
+
+	cases := []struct {
+		server string
+		want   bool
+	}{
+		{"matrix.example.org", true},
+		{"trusted.net", true},
+		{"evil.example.org", false},
+		{"untrusted.net", false},
+		{"127.0.0.1", false},
+	}
+
+	for _, c := range cases {
+		if got := acl.IsServerAllowed(c.server); got != c.want {
+			t.Errorf("IsServerAllowed(%q) = %v, want %v", c.server, got, c.want)
+		}
+	}
+}
+
+func TestIsServerAllowedDefaultsToAllowWithNoAllowList(t *testing.T) {
+	acl := &ServerACL{
+		deny: []*regexp.Regexp{globToRegexp("evil.example.org")},
+	}
+
+	if !acl.IsServerAllowed("anything.example.org") {
+		t.Error("expected server to be allowed when no allow globs are configured")
+	}
+	if acl.IsServerAllowed("evil.example.org") {
+		t.Error("expected denied server to stay denied even with no allow globs")
+	}
+}
+
+func TestIsIPLiteral(t *testing.T) {
+	cases := []struct {
+		server string
+		want   bool
+	}{
+		{"example.org", false},
+		{"127.0.0.1", true},
+		{"127.0.0.1:8448", true},
+		{"[::1]", true},
+		{"[::1]:8448", true},
+	}
+
+	for _, c := range cases {
+		if got := isIPLiteral(c.server); got != c.want {
+			t.Errorf("isIPLiteral(%q) = %v, want %v", c.server, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/t3chguy/riot-static/mxclient/storage"
+)
+
+// Client wraps the homeserver connection together with the on-disk store
+// backing it.
+type Client struct {
+	store storage.Store
+
+	mu        sync.RWMutex
+	nextBatch string
+
+	// homeserverURL, accessToken and serverName identify this client to its
+	// own homeserver (client-server API calls) and to the wider federation
+	// (the server name we sign outgoing federation requests as).
+	homeserverURL string
+	accessToken   string
+	serverName    string
+	httpClient    *http.Client
+
+	identity *federationIdentity
+}
+
+// NewClient opens the BoltDB store at storagePath and loads its persisted
+// resume marker, if any. main uses that marker to skip the full public-rooms
+// reload on a restart; NewClient itself makes no /sync request.
+// The homeserver to talk to is read from HOMESERVER_URL, ACCESS_TOKEN and
+// SERVER_NAME, matching the STORAGE_PATH convention main() already uses.
+func NewClient(storagePath string) *Client {
+	store, err := storage.NewBoltStore(storagePath)
+	if err != nil {
+		panic(err)
+	}
+
+	nextBatch, err := store.NextBatch()
+	if err != nil {
+		panic(err)
+	}
+
+	return &Client{
+		store:         store,
+		nextBatch:     nextBatch,
+		homeserverURL: os.Getenv("HOMESERVER_URL"),
+		accessToken:   os.Getenv("ACCESS_TOKEN"),
+		serverName:    os.Getenv("SERVER_NAME"),
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// doAuthenticatedGet performs a GET against this client's homeserver,
+// authenticated with its access token, and decodes the JSON response body
+// into out. Latency and failures are recorded to requestDuration /
+// requestErrors regardless of the outcome.
+func (c *Client) doAuthenticatedGet(path string, out interface{}) error {
+	start := time.Now()
+	err := c.doAuthenticatedGetUninstrumented(path, out)
+	requestDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		requestErrors.Inc()
+	}
+	return err
+}
+
+func (c *Client) doAuthenticatedGetUninstrumented(path string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.homeserverURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("mxclient: GET %s returned %d", path, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Store exposes the client's persistence layer so the forward-paginator and
+// sync loops can write room state, timeline events, and member lists through
+// it without each needing their own handle on the on-disk file.
+func (c *Client) Store() storage.Store {
+	return c.store
+}
+
+// NextBatch returns the resume marker NewClient loaded, or "" when
+// starting from a fresh store.
+func (c *Client) NextBatch() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.nextBatch
+}
+
+// SaveNextBatch persists token as the resume marker for the next process
+// start. main stores the time of its last public-rooms load here; no sync
+// token is stored.
+func (c *Client) SaveNextBatch(token string) error {
+	c.mu.Lock()
+	c.nextBatch = token
+	c.mu.Unlock()
+
+	return c.store.SaveNextBatch(token)
+}
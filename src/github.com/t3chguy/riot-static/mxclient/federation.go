@@ -0,0 +1,215 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrFederationPeekFailed is returned when none of the via servers could
+// produce a room summary for a room this client hasn't joined.
+var ErrFederationPeekFailed = errors.New("mxclient: federation peek failed")
+
+// FederationPeekNegativeCachePeriod bounds how long an unreachable room is
+// remembered as unreachable, so a bad URL doesn't repeatedly hammer servers
+// that are down or refusing to federate.
+const FederationPeekNegativeCachePeriod = 10 * time.Minute
+
+type peekCacheEntry struct {
+	preview   *RoomPreview
+	err       error
+	expiresAt time.Time
+}
+
+var (
+	peekCacheMu sync.Mutex
+	peekCache   = map[string]peekCacheEntry{}
+)
+
+// PeekCacheStatus values reported alongside a room lookup, for request logging.
+const (
+	PeekCacheLocal = "local" // room is joined; federation wasn't consulted
+	PeekCacheHit   = "hit"   // served from the federation peek cache
+	PeekCacheMiss  = "miss"  // required a live federation lookup
+)
+
+// RoomPreview is a read-only summary of a room this client has never joined,
+// resolved entirely over federation. Unlike Room it carries no timeline or
+// state: there's nothing locally synced to page through, so the preview view
+// only ever renders the summary fields below.
+type RoomPreview struct {
+	ID          string
+	Name        string
+	Topic       string
+	AvatarURL   string
+	MemberCount int
+}
+
+// GetRoomPreview resolves roomIDOrAlias to a RoomPreview purely over
+// federation: resolving aliases via /_matrix/federation/v1/query/directory
+// and then summarising the room from /_matrix/federation/v1/hierarchy,
+// falling back to the MSC3266 room-summary endpoint when hierarchy isn't
+// available. via is the list of candidate servers to try, typically taken
+// from a `?via=` query parameter. Callers should only reach for this once
+// Client.GetRoom has confirmed the room isn't already joined.
+func (c *Client) GetRoomPreview(roomIDOrAlias string, via []string) (*RoomPreview, string, error) {
+	cacheKey := roomIDOrAlias + "|" + strings.Join(via, ",")
+
+	peekCacheMu.Lock()
+	if entry, ok := peekCache[cacheKey]; ok && time.Now().Before(entry.expiresAt) {
+		peekCacheMu.Unlock()
+		return entry.preview, PeekCacheHit, entry.err
+	}
+	peekCacheMu.Unlock()
+
+	preview, err := c.peekRoomViaFederation(roomIDOrAlias, via)
+
+	peekCacheMu.Lock()
+	peekCache[cacheKey] = peekCacheEntry{preview: preview, err: err, expiresAt: time.Now().Add(FederationPeekNegativeCachePeriod)}
+	peekCacheMu.Unlock()
+
+	return preview, PeekCacheMiss, err
+}
+
+func (c *Client) peekRoomViaFederation(roomIDOrAlias string, via []string) (*RoomPreview, error) {
+	roomID := roomIDOrAlias
+	if strings.HasPrefix(roomIDOrAlias, "#") {
+		resolvedID, resolvedVia, err := c.resolveAliasViaFederation(roomIDOrAlias, via)
+		if err != nil {
+			return nil, err
+		}
+		roomID = resolvedID
+		via = append(via, resolvedVia...)
+	}
+
+	for _, server := range via {
+		summary, err := c.fetchRoomHierarchySummary(server, roomID)
+		if err != nil {
+			summary, err = c.fetchRoomSummaryMSC3266(server, roomID)
+		}
+		if err != nil {
+			continue
+		}
+		return &RoomPreview{
+			ID:          roomID,
+			Name:        summary.Name,
+			Topic:       summary.Topic,
+			AvatarURL:   summary.AvatarURL,
+			MemberCount: summary.JoinedMembersCount,
+		}, nil
+	}
+
+	return nil, ErrFederationPeekFailed
+}
+
+// roomHierarchySummary is the subset of an MSC2946 hierarchy response (or
+// its MSC3266 room-summary equivalent) needed to render a read-only preview.
+type roomHierarchySummary struct {
+	Name               string
+	Topic              string
+	AvatarURL          string
+	JoinedMembersCount int
+	RoomType           string
+}
+
+// resolveAliasViaFederation looks up a room alias's room ID and resident
+// servers via the alias's own server. The server name is everything after
+// the alias's *first* colon, since the server name itself may contain a
+// port (e.g. "#room:example.org:8448" resolves to "example.org:8448", not
+// "8448").
+func (c *Client) resolveAliasViaFederation(alias string, via []string) (roomID string, resolvedVia []string, err error) {
+	colon := strings.Index(alias, ":")
+	if colon == -1 {
+		return "", nil, fmt.Errorf("mxclient: %q is not a valid room alias", alias)
+	}
+	server := alias[colon+1:]
+
+	path := fmt.Sprintf("/_matrix/federation/v1/query/directory?room_alias=%s", url.QueryEscape(alias))
+
+	var result struct {
+		RoomID  string   `json:"room_id"`
+		Servers []string `json:"servers"`
+	}
+	if err := c.federationGet(server, path, &result); err != nil {
+		return "", nil, err
+	}
+
+	return result.RoomID, result.Servers, nil
+}
+
+func (c *Client) fetchRoomHierarchySummary(server, roomID string) (*roomHierarchySummary, error) {
+	path := fmt.Sprintf("/_matrix/federation/v1/hierarchy/%s", url.PathEscape(roomID))
+
+	var result struct {
+		Room struct {
+			Name             string `json:"name"`
+			Topic            string `json:"topic"`
+			AvatarURL        string `json:"avatar_url"`
+			NumJoinedMembers int    `json:"num_joined_members"`
+			RoomType         string `json:"room_type"`
+		} `json:"room"`
+	}
+	if err := c.federationGet(server, path, &result); err != nil {
+		return nil, err
+	}
+
+	return &roomHierarchySummary{
+		Name:               result.Room.Name,
+		Topic:              result.Room.Topic,
+		AvatarURL:          result.Room.AvatarURL,
+		JoinedMembersCount: result.Room.NumJoinedMembers,
+		RoomType:           result.Room.RoomType,
+	}, nil
+}
+
+func (c *Client) fetchRoomSummaryMSC3266(server, roomIDOrAlias string) (*roomHierarchySummary, error) {
+	path := fmt.Sprintf("/_matrix/client/unstable/im.nheko.summary/rooms/%s/summary", url.PathEscape(roomIDOrAlias))
+
+	var result struct {
+		Name             string `json:"name"`
+		Topic            string `json:"topic"`
+		AvatarURL        string `json:"avatar_url"`
+		NumJoinedMembers int    `json:"num_joined_members"`
+		RoomType         string `json:"room_type"`
+	}
+	if err := c.federationGet(server, path, &result); err != nil {
+		return nil, err
+	}
+
+	return &roomHierarchySummary{
+		Name:               result.Name,
+		Topic:              result.Topic,
+		AvatarURL:          result.AvatarURL,
+		JoinedMembersCount: result.NumJoinedMembers,
+		RoomType:           result.RoomType,
+	}, nil
+}
+
+// federationGet performs a signed federation GET against server and decodes
+// the JSON response body into out.
+func (c *Client) federationGet(server, path string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s%s", server, path), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.doSignedFederationRequest(req, out)
+}
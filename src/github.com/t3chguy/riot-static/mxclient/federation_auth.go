@@ -0,0 +1,141 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// federationIdentity is the ed25519 keypair this client signs outbound
+// federation requests with, per the server-server API's X-Matrix auth
+// scheme. It's generated once per process and held for its lifetime.
+//
+// A server that actually wants to be trusted by the wider federation also
+// needs to publish this key at /_matrix/key/v2/server so other servers can
+// validate the signature; this viewer only ever makes outbound peek
+// requests; it never accepts incoming federation traffic, so there's
+// nothing to publish.
+type federationIdentity struct {
+	keyID      string
+	privateKey ed25519.PrivateKey
+}
+
+func (c *Client) getFederationIdentity() (*federationIdentity, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.identity != nil {
+		return c.identity, nil
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	c.identity = &federationIdentity{keyID: "ed25519:static0", privateKey: priv}
+	return c.identity, nil
+}
+
+// doSignedFederationRequest signs req with this client's federation
+// identity per the server-server API's request authentication spec, sends
+// it, and decodes the JSON response body into out.
+func (c *Client) doSignedFederationRequest(req *http.Request, out interface{}) error {
+	if err := c.signFederationRequest(req); err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("mxclient: federation request to %s returned %d", req.URL.Host, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// signFederationRequest adds an X-Matrix Authorization header, signing over
+// the request method, URI, origin and destination. Federation GETs never
+// carry a body, so there's no content to fold into the signed object.
+func (c *Client) signFederationRequest(req *http.Request) error {
+	identity, err := c.getFederationIdentity()
+	if err != nil {
+		return err
+	}
+
+	toSign := map[string]interface{}{
+		"method":      req.Method,
+		"uri":         req.URL.RequestURI(),
+		"origin":      c.serverName,
+		"destination": req.URL.Host,
+	}
+
+	canonical, err := canonicalJSON(toSign)
+	if err != nil {
+		return err
+	}
+
+	sig := ed25519.Sign(identity.privateKey, canonical)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		`X-Matrix origin=%q,destination=%q,key=%q,sig=%q`,
+		c.serverName, req.URL.Host, identity.keyID, base64.RawURLEncoding.EncodeToString(sig),
+	))
+	return nil
+}
+
+// canonicalJSON produces the sorted-key, whitespace-free JSON encoding that
+// Matrix's signing algorithm requires.
+func canonicalJSON(v map[string]interface{}) ([]byte, error) {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+
+		keyJSON, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		valJSON, err := json.Marshal(v[k])
+		if err != nil {
+			return nil, err
+		}
+
+		sb.Write(keyJSON)
+		sb.WriteByte(':')
+		sb.Write(valJSON)
+	}
+	sb.WriteByte('}')
+
+	return []byte(sb.String()), nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ThumbnailURL resolves an mxc:// URI to a GET /_matrix/media/v3/thumbnail
+// request against this client's homeserver, scaled (not cropped) to the
+// given dimensions. It returns "" for anything that isn't an mxc:// URI.
+func (c *Client) ThumbnailURL(mxcURL string, width, height int) string {
+	if !strings.HasPrefix(mxcURL, "mxc://") {
+		return ""
+	}
+
+	serverAndMediaID := strings.TrimPrefix(mxcURL, "mxc://")
+	return fmt.Sprintf("%s/_matrix/media/v3/thumbnail/%s?width=%d&height=%d&method=scale",
+		c.homeserverURL, serverAndMediaID, width, height)
+}
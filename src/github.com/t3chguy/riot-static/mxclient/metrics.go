@@ -0,0 +1,36 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// requestDuration and requestErrors cover every authenticated call this
+// client makes against its own homeserver (room state, timeline, relations,
+// members), as opposed to the outbound federation peeks federation.go makes,
+// which are inherently best-effort against servers this client doesn't trust.
+var (
+	requestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "matrix_static_homeserver_request_duration_seconds",
+		Help: "Latency of authenticated client-server API requests against the homeserver.",
+	})
+
+	requestErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "matrix_static_homeserver_request_errors_total",
+		Help: "Authenticated client-server API requests against the homeserver that failed.",
+	})
+)
@@ -0,0 +1,132 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/t3chguy/riot-static/mxclient/storage"
+)
+
+// persistCursor tracks, per room, the last event ID this process has
+// already written to the store. Without it PersistTo would re-append the
+// entire loaded timeline on every forward-paginator tick, growing the
+// on-disk timeline bucket without bound.
+var (
+	persistCursorMu sync.Mutex
+	persistCursor   = map[string]string{}
+)
+
+// PersistTo appends whatever timeline events have been loaded into this
+// room since the last call through to store, so a restart can resume this
+// room's history from disk instead of re-fetching it from the homeserver.
+func (r *Room) PersistTo(store storage.Store) error {
+	// The loaded timeline is newest first; the store appends oldest first.
+	events := ReverseEventsCopy(r.GetLoadedTimeline())
+
+	persistCursorMu.Lock()
+	lastPersisted := persistCursor[r.ID]
+	persistCursorMu.Unlock()
+
+	newEvents := events
+	if lastPersisted != "" {
+		newEvents = nil
+		for i, ev := range events {
+			if ev.ID == lastPersisted {
+				newEvents = events[i+1:]
+				break
+			}
+		}
+	}
+	if len(newEvents) == 0 {
+		return nil
+	}
+
+	storageEvents := make([]storage.TimelineEvent, len(newEvents))
+	for i, ev := range newEvents {
+		content, err := json.Marshal(ev.Content)
+		if err != nil {
+			return err
+		}
+
+		storageEvents[i] = storage.TimelineEvent{
+			ID:        ev.ID,
+			Type:      ev.Type,
+			Sender:    ev.Sender,
+			Content:   content,
+			Timestamp: ev.OriginServerTS,
+		}
+	}
+
+	if err := store.AppendTimeline(r.ID, storageEvents); err != nil {
+		return err
+	}
+
+	persistCursorMu.Lock()
+	persistCursor[r.ID] = newEvents[len(newEvents)-1].ID
+	persistCursorMu.Unlock()
+
+	return nil
+}
+
+// persistedStateTypes are the singleton (state_key == "") state events worth
+// persisting: everything the public rooms list and room views need to
+// render without a fresh homeserver round-trip on resume.
+var persistedStateTypes = []string{
+	"m.room.create",
+	"m.room.name",
+	"m.room.topic",
+	"m.room.avatar",
+	"m.room.power_levels",
+	"m.room.server_acl",
+}
+
+// PersistStateTo writes this room's current singleton state events through
+// to store, so a restart can render the public rooms list and room summary
+// views from disk before the homeserver has answered any requests.
+func (r *Room) PersistStateTo(store storage.Store) error {
+	for _, eventType := range persistedStateTypes {
+		ev := r.GetStateEvent(eventType, "")
+		if ev == nil {
+			continue
+		}
+
+		content, err := json.Marshal(ev.Content)
+		if err != nil {
+			return err
+		}
+
+		if err := store.SaveRoomState(r.ID, eventType, "", content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PersistMembersTo writes this room's current member list through to store.
+func (r *Room) PersistMembersTo(store storage.Store) error {
+	members := map[string][]byte{}
+	for _, member := range r.GetMembers() {
+		content, err := json.Marshal(member)
+		if err != nil {
+			return err
+		}
+		members[member.MXID] = content
+	}
+
+	return store.SaveMembers(r.ID, members)
+}
@@ -0,0 +1,222 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package render post-processes raw timeline events into structs templates
+// can consume directly, instead of every template re-deriving "is this
+// redacted", "is this an edit", "what does this reply point at" from the
+// raw event content.
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/t3chguy/riot-static/mxclient"
+)
+
+// ThumbnailWidth and ThumbnailHeight are the dimensions requested from the
+// media repo's /thumbnail endpoint for inline images.
+const (
+	ThumbnailWidth  = 320
+	ThumbnailHeight = 240
+)
+
+// Event is a timeline event that has been sanitized and had its relations
+// (edits, replies, redactions) resolved, ready for room.html to range over.
+type Event struct {
+	*mxclient.Event
+
+	Body          template.HTML
+	Edited        bool
+	Redacted      bool
+	RedactReason  string
+	ReplyTo       *Event
+	ThumbnailURL  string
+	ThreadReplies int
+}
+
+var policy = matrixHTMLPolicy()
+
+// Events resolves raw timeline events against room (for looking up
+// in-reply-to events) and client (for resolving mxc:// URLs to thumbnail
+// URLs and counting thread replies) and returns one rendered Event per input
+// event, in the same order, except for m.replace edits, which are folded
+// into the event they replace instead of being returned.
+func Events(rawEvents []*mxclient.Event, room *mxclient.Room, client *mxclient.Client) []*Event {
+	edits := latestEditsByTarget(rawEvents)
+
+	rendered := make([]*Event, 0, len(rawEvents))
+	for _, ev := range rawEvents {
+		// Edits themselves aren't shown as standalone timeline entries;
+		// they're folded into the event they replace.
+		if relType, _ := relatesTo(ev, "rel_type").(string); relType == "m.replace" {
+			continue
+		}
+
+		rendered = append(rendered, renderEvent(ev, edits, room, client))
+	}
+	return rendered
+}
+
+func renderEvent(ev *mxclient.Event, edits map[string]*mxclient.Event, room *mxclient.Room, client *mxclient.Client) *Event {
+	out := &Event{Event: ev}
+
+	if ev.Unsigned != nil && ev.Unsigned["redacted_because"] != nil {
+		out.Redacted = true
+		if reasonContent, ok := ev.Unsigned["redacted_because"].(map[string]interface{}); ok {
+			if content, ok := reasonContent["content"].(map[string]interface{}); ok {
+				out.RedactReason, _ = content["reason"].(string)
+			}
+		}
+		return out
+	}
+
+	content := ev.Content
+	if edit, ok := edits[ev.ID]; ok && editIsFromOriginalSender(ev, edit) {
+		out.Edited = true
+		if newContent, ok := edit.Content["m.new_content"].(map[string]interface{}); ok {
+			content = newContent
+		}
+	}
+
+	out.Body = renderBody(content, client)
+
+	if replyEventID, ok := inReplyTo(content); ok {
+		if replyEvent := room.GetEvent(replyEventID); replyEvent != nil {
+			out.ReplyTo = renderEvent(replyEvent, edits, room, client)
+		}
+	}
+
+	if mxcURL, ok := content["url"].(string); ok {
+		out.ThumbnailURL = client.ThumbnailURL(mxcURL, ThumbnailWidth, ThumbnailHeight)
+	}
+
+	out.ThreadReplies = room.ThreadReplyCount(client, ev.ID)
+
+	return out
+}
+
+func renderBody(content map[string]interface{}, client *mxclient.Client) template.HTML {
+	if formattedBody, ok := content["formatted_body"].(string); ok {
+		if format, _ := content["format"].(string); format == "org.matrix.custom.html" {
+			sanitized := policy.Sanitize(stripReplyFallback(formattedBody))
+			return template.HTML(rewriteInlineImages(sanitized, client))
+		}
+	}
+
+	body, _ := content["body"].(string)
+	return template.HTML(template.HTMLEscapeString(body))
+}
+
+// mxcImgSrc matches an img tag's src once bluemonday has already sanitized
+// the surrounding HTML, so the only thing left to validate is that the
+// capture really is an mxc:// URI.
+var mxcImgSrc = regexp.MustCompile(`src="mxc://([^"]+)"`)
+
+// rewriteInlineImages replaces inline <img src="mxc://...">, which bluemonday
+// allows through as a URL scheme but which no browser can resolve on its
+// own, with the equivalent media repo thumbnail URL.
+func rewriteInlineImages(html string, client *mxclient.Client) string {
+	return mxcImgSrc.ReplaceAllStringFunc(html, func(match string) string {
+		mxcURL := "mxc://" + mxcImgSrc.FindStringSubmatch(match)[1]
+		return fmt.Sprintf(`src="%s"`, client.ThumbnailURL(mxcURL, ThumbnailWidth, ThumbnailHeight))
+	})
+}
+
+// stripReplyFallback removes the <mx-reply> block a client may have included
+// for clients that don't understand m.relates_to.m.in_reply_to, since we
+// render our own reply preview from the resolved event instead.
+func stripReplyFallback(html string) string {
+	const openTag, closeTag = "<mx-reply>", "</mx-reply>"
+	start := strings.Index(html, openTag)
+	end := strings.Index(html, closeTag)
+	if start == -1 || end == -1 || end < start {
+		return html
+	}
+	return html[:start] + html[end+len(closeTag):]
+}
+
+func latestEditsByTarget(events []*mxclient.Event) map[string]*mxclient.Event {
+	edits := map[string]*mxclient.Event{}
+	for _, ev := range events {
+		relType, _ := relatesTo(ev, "rel_type").(string)
+		if relType != "m.replace" {
+			continue
+		}
+
+		targetID, _ := relatesTo(ev, "event_id").(string)
+		if targetID == "" {
+			continue
+		}
+
+		if existing, ok := edits[targetID]; !ok || ev.OriginServerTS > existing.OriginServerTS {
+			edits[targetID] = ev
+		}
+	}
+	return edits
+}
+
+// editIsFromOriginalSender reports whether edit is a legitimate m.replace
+// for original - i.e. posted by the same sender - rather than a spoofed edit
+// from another room member trying to rewrite someone else's message.
+func editIsFromOriginalSender(original, edit *mxclient.Event) bool {
+	return edit.Sender == original.Sender
+}
+
+func inReplyTo(content map[string]interface{}) (string, bool) {
+	relatesTo, ok := content["m.relates_to"].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	inReplyTo, ok := relatesTo["m.in_reply_to"].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	eventID, ok := inReplyTo["event_id"].(string)
+	return eventID, ok
+}
+
+func relatesTo(ev *mxclient.Event, key string) interface{} {
+	relatesTo, ok := ev.Content["m.relates_to"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return relatesTo[key]
+}
+
+// matrixHTMLPolicy allowlists the subset of HTML the spec permits in
+// formatted_body (https://spec.matrix.org/latest/client-server-api/#mroommessage-msgtypes),
+// plus matrix.to pill links and spoilers.
+func matrixHTMLPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements("del", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "p",
+		"ul", "ol", "li", "b", "i", "u", "strong", "em", "strike", "code", "hr",
+		"br", "div", "table", "thead", "tbody", "tr", "th", "td", "caption",
+		"pre", "span", "a", "img", "sub", "sup")
+
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("src", "width", "height", "alt", "title").OnElements("img")
+	p.AllowAttrs("data-mx-spoiler").OnElements("span")
+	p.AllowAttrs("color", "data-mx-color", "data-mx-bg-color").OnElements("font", "span")
+	p.AllowElements("font")
+
+	p.AllowURLSchemes("https", "http", "mailto", "matrix", "mxc")
+	p.RequireNoFollowOnLinks(true)
+
+	return p
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"testing"
+
+	"github.com/t3chguy/riot-static/mxclient"
+)
+
+func TestEditIsFromOriginalSenderRejectsSpoofedEdit(t *testing.T) {
+	original := &mxclient.Event{ID: "$original", Sender: "@alice:example.org"}
+	spoofedEdit := &mxclient.Event{ID: "$edit", Sender: "@mallory:example.org"}
+
+	if editIsFromOriginalSender(original, spoofedEdit) {
+		t.Error("expected edit from a different sender to be rejected")
+	}
+}
+
+func TestEditIsFromOriginalSenderAcceptsLegitEdit(t *testing.T) {
+	original := &mxclient.Event{ID: "$original", Sender: "@alice:example.org"}
+	legitEdit := &mxclient.Event{ID: "$edit", Sender: "@alice:example.org"}
+
+	if !editIsFromOriginalSender(original, legitEdit) {
+		t.Error("expected edit from the same sender to be accepted")
+	}
+}
+
+func TestLatestEditsByTargetPicksMostRecent(t *testing.T) {
+	target := &mxclient.Event{ID: "$original"}
+	earlierEdit := &mxclient.Event{
+		ID: "$edit1", OriginServerTS: 100,
+		Content: map[string]interface{}{
+			"m.relates_to": map[string]interface{}{"rel_type": "m.replace", "event_id": "$original"},
+		},
+	}
+	laterEdit := &mxclient.Event{
+		ID: "$edit2", OriginServerTS: 200,
+		Content: map[string]interface{}{
+			"m.relates_to": map[string]interface{}{"rel_type": "m.replace", "event_id": "$original"},
+		},
+	}
+
+	edits := latestEditsByTarget([]*mxclient.Event{target, earlierEdit, laterEdit})
+
+	if got := edits["$original"]; got == nil || got.ID != "$edit2" {
+		t.Errorf("latestEditsByTarget picked %v, want $edit2", got)
+	}
+}
+
+func TestRewriteInlineImages(t *testing.T) {
+	client := &mxclient.Client{}
+
+	html := `<p>look:</p><img src="mxc://example.org/abc123" alt="">`
+	got := rewriteInlineImages(html, client)
+	want := `<p>look:</p><img src="/_matrix/media/v3/thumbnail/example.org/abc123?width=320&height=240&method=scale" alt="">`
+
+	if got != want {
+		t.Errorf("rewriteInlineImages = %q, want %q", got, want)
+	}
+}
+
+func TestStripReplyFallback(t *testing.T) {
+	html := `<mx-reply><blockquote>quoted</blockquote></mx-reply>actual reply body`
+	got := stripReplyFallback(html)
+	want := "actual reply body"
+
+	if got != want {
+		t.Errorf("stripReplyFallback = %q, want %q", got, want)
+	}
+}
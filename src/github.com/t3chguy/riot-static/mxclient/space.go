@@ -0,0 +1,229 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrSpaceNotFound is returned by GetSpace when the given room ID does not
+// resolve to a room this client knows about, or the room has no
+// m.room.create with type m.space.
+var ErrSpaceNotFound = errors.New("mxclient: space not found")
+
+// DefaultSpaceHierarchyDepth bounds how many levels of nested subspaces
+// GetSpace will spider into when a caller doesn't specify their own depth.
+const DefaultSpaceHierarchyDepth = 3
+
+// SpaceSummaryCachePeriod mirrors the refresh cadence of the public rooms
+// cache so that space summaries don't go stale for longer than that list does.
+const SpaceSummaryCachePeriod = time.Hour
+
+// SpaceChild is a single m.space.child entry resolved against the room or
+// subspace it points at.
+type SpaceChild struct {
+	Room      *Room
+	Space     *Space
+	Order     string
+	Suggested bool
+	Via       []string
+}
+
+// Space is a resolved view of a room with type m.space: its own summary
+// fields plus the ordered, deduplicated children pulled out of its
+// m.space.child state.
+type Space struct {
+	ID          string
+	Name        string
+	Topic       string
+	AvatarURL   string
+	MemberCount int
+	Children    []*SpaceChild
+}
+
+type spaceCacheEntry struct {
+	space     *Space
+	expiresAt time.Time
+}
+
+var (
+	spaceCacheMu sync.Mutex
+	spaceCache   = map[string]spaceCacheEntry{}
+)
+
+// GetSpace resolves spaceID's m.space.child state into an ordered list of
+// children, recursively spidering into nested spaces up to maxDepth levels.
+// maxDepth <= 0 resolves only the requested space's direct children.
+func (c *Client) GetSpace(spaceID string, maxDepth int) (*Space, error) {
+	return c.getSpace(spaceID, maxDepth, map[string]bool{})
+}
+
+func (c *Client) getSpace(spaceID string, depth int, ancestors map[string]bool) (*Space, error) {
+	// A space revisited via an ancestor edge is a genuine cycle; a space
+	// revisited via a sibling branch (a diamond) is not, and should still
+	// render as a reference rather than coming back blank.
+	if ancestors[spaceID] {
+		return nil, nil
+	}
+	ancestors[spaceID] = true
+	defer delete(ancestors, spaceID)
+
+	cacheKey := spaceCacheKey(spaceID, depth)
+
+	spaceCacheMu.Lock()
+	if entry, ok := spaceCache[cacheKey]; ok && time.Now().Before(entry.expiresAt) {
+		spaceCacheMu.Unlock()
+		return entry.space, nil
+	}
+	spaceCacheMu.Unlock()
+
+	room := c.GetRoom(spaceID)
+	if room == nil || !room.LazyInitialSync() || !room.IsSpace() {
+		return nil, ErrSpaceNotFound
+	}
+
+	space := &Space{
+		ID:          spaceID,
+		Name:        room.Name(),
+		Topic:       room.Topic(),
+		AvatarURL:   room.AvatarURL(),
+		MemberCount: room.MemberCount(),
+	}
+
+	seenChildren := map[string]bool{}
+	for _, ev := range room.GetStateEventsOfType("m.space.child") {
+		if ev.StateKey == nil || *ev.StateKey == "" || seenChildren[*ev.StateKey] {
+			continue
+		}
+
+		via, _ := ev.Content["via"].([]interface{})
+		if len(via) == 0 {
+			// An empty via list means the child has been removed from the space.
+			continue
+		}
+		seenChildren[*ev.StateKey] = true
+
+		child := &SpaceChild{Via: stringSlice(via)}
+		if order, ok := ev.Content["order"].(string); ok {
+			child.Order = order
+		}
+		if suggested, ok := ev.Content["suggested"].(bool); ok {
+			child.Suggested = suggested
+		}
+
+		childRoom := c.GetRoom(*ev.StateKey)
+		if childRoom != nil && childRoom.IsSpace() {
+			if depth > 0 {
+				childSpace, err := c.getSpace(*ev.StateKey, depth-1, ancestors)
+				if err == nil && childSpace != nil {
+					child.Space = childSpace
+				}
+			}
+		} else {
+			child.Room = childRoom
+		}
+
+		space.Children = append(space.Children, child)
+	}
+
+	sortSpaceChildren(space.Children)
+
+	spaceCacheMu.Lock()
+	spaceCache[cacheKey] = spaceCacheEntry{space: space, expiresAt: time.Now().Add(SpaceSummaryCachePeriod)}
+	spaceCacheMu.Unlock()
+
+	return space, nil
+}
+
+// spaceCacheKey incorporates depth because a shallow summary (e.g. the
+// depth-0 lookup GetRoomsBySpace does for every space room) and a deep
+// hierarchy render are not interchangeable.
+func spaceCacheKey(spaceID string, depth int) string {
+	return spaceID + "|" + strconv.Itoa(depth)
+}
+
+// sortSpaceChildren orders children per MSC1772: lexicographically by the
+// `order` field first (children without one sort last), falling back to
+// room ID to keep ties stable.
+func sortSpaceChildren(children []*SpaceChild) {
+	sort.SliceStable(children, func(i, j int) bool {
+		oi, oj := children[i].Order, children[j].Order
+		if oi == "" && oj != "" {
+			return false
+		}
+		if oi != "" && oj == "" {
+			return true
+		}
+		if oi != oj {
+			return oi < oj
+		}
+		return childRoomID(children[i]) < childRoomID(children[j])
+	})
+}
+
+func childRoomID(c *SpaceChild) string {
+	if c.Room != nil {
+		return c.Room.ID
+	}
+	if c.Space != nil {
+		return c.Space.ID
+	}
+	return ""
+}
+
+// GetRoomsBySpace groups the currently known public rooms by the spaces
+// that list them as an m.space.child, for the "Browse spaces" tab on the
+// public rooms index. Rooms that aren't referenced by any space are omitted.
+func (c *Client) GetRoomsBySpace() []*Space {
+	var spaces []*Space
+	for _, room := range c.GetRoomList(0, -1) {
+		if !room.IsSpace() {
+			continue
+		}
+
+		space, err := c.GetSpace(room.ID, 0)
+		if err != nil || space == nil || len(space.Children) == 0 {
+			continue
+		}
+
+		spaces = append(spaces, space)
+	}
+	return spaces
+}
+
+// IsSpace reports whether this room's m.room.create declares type m.space,
+// per MSC1772.
+func (r *Room) IsSpace() bool {
+	ev := r.GetStateEvent("m.room.create", "")
+	if ev == nil {
+		return false
+	}
+	roomType, _ := ev.Content["type"].(string)
+	return roomType == "m.space"
+}
+
+func stringSlice(raw []interface{}) []string {
+	out := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
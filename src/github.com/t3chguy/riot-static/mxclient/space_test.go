@@ -0,0 +1,68 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortSpaceChildrenByOrder(t *testing.T) {
+	children := []*SpaceChild{
+		{Space: &Space{ID: "!b:example.org"}, Order: "2"},
+		{Space: &Space{ID: "!a:example.org"}, Order: "1"},
+		{Space: &Space{ID: "!c:example.org"}},
+	}
+
+	sortSpaceChildren(children)
+
+	var got []string
+	for _, c := range children {
+		got = append(got, childRoomID(c))
+	}
+
+	want := []string{"!a:example.org", "!b:example.org", "!c:example.org"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sortSpaceChildren order = %v, want %v", got, want)
+	}
+}
+
+func TestSortSpaceChildrenFallsBackToIDWhenOrderTies(t *testing.T) {
+	children := []*SpaceChild{
+		{Space: &Space{ID: "!z:example.org"}},
+		{Space: &Space{ID: "!a:example.org"}},
+	}
+
+	sortSpaceChildren(children)
+
+	var got []string
+	for _, c := range children {
+		got = append(got, childRoomID(c))
+	}
+
+	want := []string{"!a:example.org", "!z:example.org"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sortSpaceChildren order = %v, want %v", got, want)
+	}
+}
+
+func TestStringSlice(t *testing.T) {
+	raw := []interface{}{"example.org", 5, "matrix.org", nil}
+	got := stringSlice(raw)
+	want := []string{"example.org", "matrix.org"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stringSlice(%v) = %v, want %v", raw, got, want)
+	}
+}
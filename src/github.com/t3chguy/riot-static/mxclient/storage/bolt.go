@@ -0,0 +1,206 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "github.com/boltdb/bolt"
+)
+
+var (
+	bucketMeta      = []byte("meta")
+	bucketRoomState = []byte("room_state")
+	bucketTimeline  = []byte("timeline")
+	bucketMembers   = []byte("members")
+
+	keyNextBatch = []byte("next_batch")
+)
+
+// BoltStore is the default Store implementation, backed by a single BoltDB
+// file. It's a reasonable default for the single-process deployments this
+// viewer targets; a SQLite-backed Store can satisfy the same interface for
+// deployments that want to inspect the cache with off-the-shelf tooling.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (creating if necessary) a BoltDB file at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{bucketMeta, bucketRoomState, bucketTimeline, bucketMembers} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) NextBatch() (string, error) {
+	var token string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		token = string(tx.Bucket(bucketMeta).Get(keyNextBatch))
+		return nil
+	})
+	return token, err
+}
+
+func (s *BoltStore) SaveNextBatch(token string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keyNextBatch, []byte(token))
+	})
+}
+
+func (s *BoltStore) RoomState(roomID string) (map[string][]byte, error) {
+	state := map[string][]byte{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketRoomState).Bucket([]byte(roomID))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			cp := make([]byte, len(v))
+			copy(cp, v)
+			state[string(k)] = cp
+			return nil
+		})
+	})
+	return state, err
+}
+
+func (s *BoltStore) SaveRoomState(roomID, eventType, stateKey string, content []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(bucketRoomState).CreateBucketIfNotExists([]byte(roomID))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(eventType+"\x1f"+stateKey), content)
+	})
+}
+
+func (s *BoltStore) Timeline(roomID, beforeEventID string, limit int) ([]TimelineEvent, error) {
+	var events []TimelineEvent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketTimeline).Bucket([]byte(roomID))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		k, v := c.Last()
+		if beforeEventID != "" {
+			k, v = seekBefore(c, beforeEventID)
+		}
+
+		for ; k != nil && len(events) < limit; k, v = c.Prev() {
+			var ev TimelineEvent
+			if err := json.Unmarshal(v, &ev); err != nil {
+				return err
+			}
+			events = append(events, ev)
+		}
+		return nil
+	})
+	return events, err
+}
+
+func seekBefore(c *bolt.Cursor, eventID string) (k, v []byte) {
+	for k, v = c.Last(); k != nil; k, v = c.Prev() {
+		var ev TimelineEvent
+		if json.Unmarshal(v, &ev) == nil && ev.ID == eventID {
+			return c.Prev()
+		}
+	}
+	return nil, nil
+}
+
+func (s *BoltStore) AppendTimeline(roomID string, events []TimelineEvent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(bucketTimeline).CreateBucketIfNotExists([]byte(roomID))
+		if err != nil {
+			return err
+		}
+
+		for _, ev := range events {
+			seq, _ := bucket.NextSequence()
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(sequenceKey(seq), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+func (s *BoltStore) Members(roomID string) (map[string][]byte, error) {
+	members := map[string][]byte{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketMembers).Bucket([]byte(roomID))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			cp := make([]byte, len(v))
+			copy(cp, v)
+			members[string(k)] = cp
+			return nil
+		})
+	})
+	return members, err
+}
+
+func (s *BoltStore) SaveMembers(roomID string, members map[string][]byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(bucketMembers).CreateBucketIfNotExists([]byte(roomID))
+		if err != nil {
+			return fmt.Errorf("storage: creating members bucket for %s: %w", roomID, err)
+		}
+
+		for mxid, content := range members {
+			if err := bucket.Put([]byte(mxid), content); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
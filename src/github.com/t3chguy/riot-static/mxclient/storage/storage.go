@@ -0,0 +1,52 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storage persists a resume marker, room state, timeline events and
+// member lists across restarts.
+package storage
+
+// Store is the pluggable persistence layer backing a Client. Implementations
+// must be safe for concurrent use.
+type Store interface {
+	// NextBatch returns the last persisted resume marker, or "" if this is a
+	// fresh store with nothing to resume from.
+	NextBatch() (string, error)
+	SaveNextBatch(token string) error
+
+	// RoomState returns the last persisted state snapshot for roomID,
+	// keyed by "type\x1fstate_key", or an empty map if nothing is stored yet.
+	RoomState(roomID string) (map[string][]byte, error)
+	SaveRoomState(roomID string, eventType, stateKey string, content []byte) error
+
+	// Timeline returns up to limit persisted timeline events for roomID,
+	// most recent first, starting from before the given event ID (empty
+	// for the most recent page).
+	Timeline(roomID, beforeEventID string, limit int) ([]TimelineEvent, error)
+	AppendTimeline(roomID string, events []TimelineEvent) error
+
+	Members(roomID string) (map[string][]byte, error)
+	SaveMembers(roomID string, members map[string][]byte) error
+
+	Close() error
+}
+
+// TimelineEvent is the minimal persisted shape of a timeline event; callers
+// are expected to unmarshal Content into their own event struct.
+type TimelineEvent struct {
+	ID        string
+	Type      string
+	Sender    string
+	Content   []byte
+	Timestamp int64
+}
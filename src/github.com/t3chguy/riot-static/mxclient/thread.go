@@ -0,0 +1,163 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ThreadCachePeriod bounds how long a thread's reply list is trusted before
+// being re-scanned, matching the cadence new timeline events arrive at via
+// the forward paginator.
+const ThreadCachePeriod = time.Minute
+
+// threadCacheEntry holds the event IDs of every reply a thread root has
+// seen, so a repeat view of the same thread doesn't re-fetch its relations.
+type threadCacheEntry struct {
+	replyIDs  []string
+	expiresAt time.Time
+}
+
+var (
+	threadCacheMu sync.Mutex
+	threadCache   = map[string]*threadCacheEntry{}
+)
+
+// GetThread returns a page of replies to rootEventID's m.thread relation,
+// in chronological order, along with the same RoomEventsError enum
+// GetEventPage uses.
+func (r *Room) GetThread(client *Client, rootEventID string, offset, pageSize int) ([]Event, RoomEventsError) {
+	replyIDs, err := r.threadReplyIDs(client, rootEventID)
+	if err != RoomEventsFine {
+		return nil, err
+	}
+
+	if offset >= len(replyIDs) {
+		return nil, RoomEventsFine
+	}
+
+	end := offset + pageSize
+	if end > len(replyIDs) {
+		end = len(replyIDs)
+	}
+
+	events := make([]Event, 0, end-offset)
+	for _, eventID := range replyIDs[offset:end] {
+		ev := r.GetEvent(eventID)
+		if ev == nil {
+			return nil, RoomEventsCouldNotFindEvent
+		}
+		events = append(events, *ev)
+	}
+
+	return events, RoomEventsFine
+}
+
+// ThreadReplyCount returns how many replies rootEventID's thread has, for
+// the "N replies in thread" affordance under a thread root in the chat view.
+func (r *Room) ThreadReplyCount(client *Client, rootEventID string) int {
+	replyIDs, err := r.threadReplyIDs(client, rootEventID)
+	if err != RoomEventsFine {
+		return 0
+	}
+	return len(replyIDs)
+}
+
+// threadReplyIDs always consults the homeserver's relations endpoint first,
+// since it's the only authoritative source for a thread's full reply list -
+// the locally loaded timeline window frequently doesn't go back far enough
+// to contain every reply, so trusting it alone silently undercounts long
+// threads. It only falls back to whatever the loaded timeline covers when
+// the homeserver call itself fails.
+func (r *Room) threadReplyIDs(client *Client, rootEventID string) ([]string, RoomEventsError) {
+	cacheKey := r.ID + "|" + rootEventID
+
+	threadCacheMu.Lock()
+	if entry, ok := threadCache[cacheKey]; ok && time.Now().Before(entry.expiresAt) {
+		threadCacheMu.Unlock()
+		return entry.replyIDs, RoomEventsFine
+	}
+	threadCacheMu.Unlock()
+
+	replyIDs, err := r.fetchThreadRelationsFromServer(client, rootEventID)
+	if err != nil {
+		replyIDs = r.localThreadReplyIDs(rootEventID)
+		if replyIDs == nil {
+			return nil, RoomEventsUnknownError
+		}
+	}
+
+	threadCacheMu.Lock()
+	threadCache[cacheKey] = &threadCacheEntry{replyIDs: replyIDs, expiresAt: time.Now().Add(ThreadCachePeriod)}
+	threadCacheMu.Unlock()
+
+	return replyIDs, RoomEventsFine
+}
+
+// localThreadReplyIDs scans whatever timeline events are already loaded for
+// replies to rootEventID. It's the fallback path for when the homeserver
+// can't be reached, not the primary source of truth.
+func (r *Room) localThreadReplyIDs(rootEventID string) []string {
+	return filterThreadReplyIDs(ReverseEventsCopy(r.GetLoadedTimeline()), rootEventID)
+}
+
+func filterThreadReplyIDs(events []Event, rootEventID string) []string {
+	var replyIDs []string
+	for _, ev := range events {
+		relatesTo, ok := ev.Content["m.relates_to"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		relType, _ := relatesTo["rel_type"].(string)
+		eventID, _ := relatesTo["event_id"].(string)
+		if relType == "m.thread" && eventID == rootEventID {
+			replyIDs = append(replyIDs, ev.ID)
+		}
+	}
+	return replyIDs
+}
+
+// GetLoadedTimeline returns every event currently held in this room's
+// timeline window, without triggering further backward pagination.
+func (r *Room) GetLoadedTimeline() []Event {
+	events, _ := r.GetEventPage("", 0, math.MaxInt32)
+	return events
+}
+
+// fetchThreadRelationsFromServer calls GET
+// /_matrix/client/v1/rooms/{roomId}/relations/{eventId}/m.thread, returning
+// the reply event IDs in chronological order.
+func (r *Room) fetchThreadRelationsFromServer(client *Client, rootEventID string) ([]string, error) {
+	var result struct {
+		Chunk []Event `json:"chunk"`
+	}
+
+	path := fmt.Sprintf("/_matrix/client/v1/rooms/%s/relations/%s/m.thread?dir=f",
+		url.PathEscape(r.ID), url.PathEscape(rootEventID))
+	if err := client.doAuthenticatedGet(path, &result); err != nil {
+		return nil, err
+	}
+
+	replyIDs := make([]string, len(result.Chunk))
+	for i, ev := range result.Chunk {
+		replyIDs[i] = ev.ID
+	}
+	return replyIDs, nil
+}
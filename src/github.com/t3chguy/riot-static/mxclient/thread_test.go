@@ -0,0 +1,63 @@
+// Copyright 2017 Michael Telatynski <7t3chguy@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mxclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterThreadReplyIDs(t *testing.T) {
+	events := []Event{
+		{ID: "$root"},
+		{
+			ID: "$reply1",
+			Content: map[string]interface{}{
+				"m.relates_to": map[string]interface{}{"rel_type": "m.thread", "event_id": "$root"},
+			},
+		},
+		{
+			ID: "$unrelated",
+			Content: map[string]interface{}{
+				"m.relates_to": map[string]interface{}{"rel_type": "m.thread", "event_id": "$someOtherRoot"},
+			},
+		},
+		{
+			ID: "$edit",
+			Content: map[string]interface{}{
+				"m.relates_to": map[string]interface{}{"rel_type": "m.replace", "event_id": "$root"},
+			},
+		},
+		{
+			ID: "$reply2",
+			Content: map[string]interface{}{
+				"m.relates_to": map[string]interface{}{"rel_type": "m.thread", "event_id": "$root"},
+			},
+		},
+	}
+
+	got := filterThreadReplyIDs(events, "$root")
+	want := []string{"$reply1", "$reply2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterThreadReplyIDs = %v, want %v", got, want)
+	}
+}
+
+func TestFilterThreadReplyIDsNoMatches(t *testing.T) {
+	events := []Event{{ID: "$root"}, {ID: "$unrelated"}}
+	if got := filterThreadReplyIDs(events, "$root"); got != nil {
+		t.Errorf("filterThreadReplyIDs = %v, want nil", got)
+	}
+}
@@ -17,7 +17,10 @@ package main
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
 	"github.com/t3chguy/riot-static/mxclient"
+	"github.com/t3chguy/riot-static/mxclient/render"
 	"github.com/t3chguy/riot-static/utils"
 	"net/http"
 	"os"
@@ -25,36 +28,79 @@ import (
 	"time"
 )
 
-// TODO Cache memberList+serverList until it changes
-
 const PublicRoomsPageSize = 20
 const RoomTimelineSize = 20
 const RoomMembersPageSize = 20
 
 func main() {
-	client := mxclient.NewClient()
+	storagePath := os.Getenv("STORAGE_PATH")
+	if storagePath == "" {
+		storagePath = "./data/matrix-static.db"
+	}
+
+	client := mxclient.NewClient(storagePath)
 
 	templates := InitTemplates(client)
 
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), structuredLogger())
 	router.SetHTMLTemplate(templates)
 	router.Static("/img", "./assets/img")
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	router.GET("/", func(c *gin.Context) {
 		page, skip, end := utils.CalcPaginationPage(c.DefaultQuery("page", "1"), PublicRoomsPageSize)
 		c.HTML(http.StatusOK, "rooms.html", gin.H{
-			"Rooms": client.GetRoomList(skip, end),
-			"Page":  page,
+			"Rooms":    client.GetRoomList(skip, end),
+			"Page":     page,
+			"PrevPage": page - 1,
+			"NextPage": page + 1,
+			"Spaces":   client.GetRoomsBySpace(),
 		})
 	})
 
+	spaceRouter := router.Group("/space/")
+	{
+		spaceRouter.Use(func(c *gin.Context) {
+			spaceID := c.Param("spaceID")
+
+			space, err := client.GetSpace(spaceID, mxclient.DefaultSpaceHierarchyDepth)
+			if err != nil {
+				c.String(http.StatusNotFound, "Space Not Found")
+				c.Abort()
+				return
+			}
+
+			c.Set("Space", space)
+			c.Next()
+		})
+
+		spaceRouter.GET("/:spaceID/", func(c *gin.Context) {
+			c.HTML(http.StatusOK, "space.html", gin.H{
+				"Space": c.MustGet("Space").(*mxclient.Space),
+			})
+		})
+
+		spaceRouter.GET("/:spaceID/hierarchy", func(c *gin.Context) {
+			c.HTML(http.StatusOK, "space_hierarchy.html", gin.H{
+				"Space": c.MustGet("Space").(*mxclient.Space),
+			})
+		})
+	}
+
 	roomRouter := router.Group("/room/")
 	{
-		// Load room into request object so that we can do any clean up etc here
+		// Load room into request object so that we can do any clean up etc here.
+		// A room this client has joined gets the full interactive view; a
+		// room it's only previewing over federation gets a read-only summary
+		// instead, since a federation peek carries no timeline or state to
+		// page through.
 		roomRouter.Use(func(c *gin.Context) {
 			roomID := c.Param("roomID")
 
 			if room := client.GetRoom(roomID); room != nil {
+				c.Set("CacheStatus", mxclient.PeekCacheLocal)
+
 				if room.LazyInitialSync() {
 					c.Set("Room", room)
 					c.Next()
@@ -65,10 +111,23 @@ func main() {
 					})
 					c.Abort()
 				}
-			} else {
+				return
+			}
+
+			via := c.QueryArray("via")
+			preview, cacheStatus, err := client.GetRoomPreview(roomID, via)
+			c.Set("CacheStatus", cacheStatus)
+
+			if err != nil || preview == nil {
 				c.String(http.StatusNotFound, "Room Not Found")
 				c.Abort()
+				return
 			}
+
+			c.HTML(http.StatusOK, "room_preview.html", gin.H{
+				"Preview": preview,
+			})
+			c.Abort()
 		})
 
 		roomRouter.GET("/:roomID/", func(c *gin.Context) {
@@ -119,18 +178,22 @@ func main() {
 
 			c.HTML(http.StatusOK, "room.html", gin.H{
 				"Room":     room,
-				"Events":   events,
+				"Events":   render.Events(eventPointers(events), room, client),
 				"PageSize": pageSize,
 
 				"ReachedRoomCreate": reachedRoomCreate,
 				"CurrentOffset":     offset,
+				"NextOffset":        offset + pageSize,
 				"Anchor":            eventID,
 			})
 		})
 
 		roomRouter.GET("/:roomID/servers", func(c *gin.Context) {
+			room := c.MustGet("Room").(*mxclient.Room)
+
 			c.HTML(http.StatusOK, "room_servers.html", gin.H{
-				"Room": c.MustGet("Room").(*mxclient.Room),
+				"Room": room,
+				"ACL":  room.ServerACL(),
 			})
 		})
 
@@ -142,6 +205,9 @@ func main() {
 				"Room":       room,
 				"MemberInfo": room.GetMembers()[skip:end],
 				"Page":       page,
+				"PrevPage":   page - 1,
+				"NextPage":   page + 1,
+				"ACL":        room.ServerACL(),
 			})
 		})
 
@@ -159,6 +225,45 @@ func main() {
 			}
 		})
 
+		roomRouter.GET("/:roomID/thread/:eventID", func(c *gin.Context) {
+			room := c.MustGet("Room").(*mxclient.Room)
+			rootEventID := c.Param("eventID")
+
+			pageSize := RoomTimelineSize
+
+			var offset int
+			if offsetStr, exists := c.GetQuery("offset"); exists {
+				num, err := strconv.Atoi(offsetStr)
+				if err == nil {
+					offset = num
+				}
+			}
+
+			root := room.GetEvent(rootEventID)
+			if root == nil {
+				c.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+
+			replies, repliesErr := room.GetThread(client, rootEventID, offset, pageSize)
+			if repliesErr != mxclient.RoomEventsFine {
+				c.HTML(http.StatusInternalServerError, "room_error.html", gin.H{
+					"Error": "Failed to load thread.",
+					"Room":  room,
+				})
+				return
+			}
+
+			c.HTML(http.StatusOK, "room_thread.html", gin.H{
+				"Room":          room,
+				"Root":          render.Events([]*mxclient.Event{root}, room, client)[0],
+				"Events":        render.Events(eventPointers(replies), room, client),
+				"PageSize":      pageSize,
+				"CurrentOffset": offset,
+				"NextOffset":    offset + pageSize,
+			})
+		})
+
 		roomRouter.GET("/:roomID/power_levels", func(c *gin.Context) {
 			c.HTML(http.StatusOK, "power_levels.html", gin.H{
 				"Room": c.MustGet("Room").(*mxclient.Room),
@@ -171,7 +276,7 @@ func main() {
 		port = "8000"
 	}
 
-	LoadPublicRooms(client, true)
+	resumeOrLoadPublicRooms(client)
 	go startForwardPaginator(client)
 	go startPublicRoomListTimer(client)
 	fmt.Println("Listening on port " + port)
@@ -187,13 +292,39 @@ func main() {
 	panic(srv.ListenAndServe())
 }
 
+func eventPointers(events []mxclient.Event) []*mxclient.Event {
+	pointers := make([]*mxclient.Event, len(events))
+	for i := range events {
+		pointers[i] = &events[i]
+	}
+	return pointers
+}
+
+// resumeOrLoadPublicRooms only forces a full public-rooms reload on a cold
+// start (no persisted resume marker yet); a restart with a store already on
+// disk only needs the regular incremental refresh the periodic timer does.
+// After refreshing, it records a new resume marker so the *next* restart
+// can skip the full reload too.
+func resumeOrLoadPublicRooms(client *mxclient.Client) {
+	forceFullLoad := client.NextBatch() == ""
+
+	LoadPublicRooms(client, forceFullLoad)
+
+	if err := client.SaveNextBatch(time.Now().Format(time.RFC3339Nano)); err != nil {
+		log.Error().Err(err).Msg("failed to persist resume marker")
+	}
+}
+
 const LoadPublicRoomsPeriod = time.Hour
 
 func startPublicRoomListTimer(client *mxclient.Client) {
 	t := time.NewTicker(LoadPublicRoomsPeriod)
 	for {
 		<-t.C
+
+		start := time.Now()
 		LoadPublicRooms(client, false)
+		publicRoomListRefreshDuration.Observe(time.Since(start).Seconds())
 	}
 }
 
@@ -203,8 +334,24 @@ func startForwardPaginator(client *mxclient.Client) {
 	t := time.NewTicker(LazyForwardPaginateRooms)
 	for {
 		<-t.C
-		for _, room := range client.GetRoomList(0, -1) {
+
+		start := time.Now()
+		rooms := client.GetRoomList(0, -1)
+		roomCount.Set(float64(len(rooms)))
+		for _, room := range rooms {
 			room.LazyUpdateRoom()
+			roomMemberCount.WithLabelValues(room.ID).Set(float64(room.MemberCount()))
+
+			if err := room.PersistTo(client.Store()); err != nil {
+				log.Error().Err(err).Str("room_id", room.ID).Msg("failed to persist room timeline to store")
+			}
+			if err := room.PersistStateTo(client.Store()); err != nil {
+				log.Error().Err(err).Str("room_id", room.ID).Msg("failed to persist room state to store")
+			}
+			if err := room.PersistMembersTo(client.Store()); err != nil {
+				log.Error().Err(err).Str("room_id", room.ID).Msg("failed to persist room members to store")
+			}
 		}
+		forwardPaginationDuration.Observe(time.Since(start).Seconds())
 	}
 }